@@ -0,0 +1,173 @@
+/*
+Copyright 2018 The Ceph-CSI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/ceph/ceph-csi/internal/util/log"
+)
+
+// fscrypt ioctl numbers and on-disk/UAPI layout, see <linux/fscrypt.h>.
+const (
+	fsIocAddEncryptionKey    = 0xc0506617
+	fsIocSetEncryptionPolicy = 0x800c6613
+
+	fscryptKeySpecTypeIdentifier = 2
+	fscryptKeyIdentifierSize     = 16
+	fscryptKeySpecSize           = 4 + 4 + 32 // type + __reserved + union
+
+	fscryptModeAes256Xts = 1
+	fscryptModeAes256Cts = 4
+	fscryptPolicyV2      = 2
+
+	// fscryptPolicyFlagPad32 pads filenames to a multiple of 32 bytes.
+	fscryptPolicyFlagPad32 = 0x3
+)
+
+// addEncryptionKey installs passphrase as an fscrypt key on the filesystem
+// mounted at mountPoint and returns the key identifier the kernel assigned
+// to it.
+func addEncryptionKey(mountPoint, passphrase string) ([fscryptKeyIdentifierSize]byte, error) {
+	var identifier [fscryptKeyIdentifierSize]byte
+
+	f, err := os.Open(mountPoint)
+	if err != nil {
+		return identifier, fmt.Errorf("failed to open %q: %w", mountPoint, err)
+	}
+	defer f.Close()
+
+	buf := buildAddKeyArg([]byte(passphrase))
+
+	if err := ioctl(f, fsIocAddEncryptionKey, unsafe.Pointer(&buf[0])); err != nil {
+		return identifier, fmt.Errorf("FS_IOC_ADD_ENCRYPTION_KEY failed: %w", err)
+	}
+
+	// On success the kernel overwrites key_spec.u with the 16-byte
+	// identifier it derived from the key.
+	copy(identifier[:], buf[8:8+fscryptKeyIdentifierSize])
+
+	return identifier, nil
+}
+
+// buildAddKeyArg lays out a struct fscrypt_add_key_arg followed by the raw
+// key bytes:
+//
+//	struct fscrypt_add_key_arg {
+//	  struct fscrypt_key_specifier key_spec;
+//	  __u32 raw_size;
+//	  __u32 key_id;
+//	  __u32 __reserved[8];
+//	  __u8 raw[];
+//	};
+func buildAddKeyArg(key []byte) []byte {
+	rawSizeOff := fscryptKeySpecSize
+	rawOff := rawSizeOff + 4 + 4 + 8*4
+
+	buf := make([]byte, rawOff+len(key))
+	binary.LittleEndian.PutUint32(buf[0:4], fscryptKeySpecTypeIdentifier)
+	binary.LittleEndian.PutUint32(buf[rawSizeOff:rawSizeOff+4], uint32(len(key)))
+	copy(buf[rawOff:], key)
+
+	return buf
+}
+
+// setEncryptionPolicy applies an fscrypt v2 policy keyed by identifier to
+// the directory mounted at mountPoint.
+func setEncryptionPolicy(mountPoint string, identifier [fscryptKeyIdentifierSize]byte) error {
+	f, err := os.Open(mountPoint)
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", mountPoint, err)
+	}
+	defer f.Close()
+
+	// struct fscrypt_policy_v2 {
+	//   __u8 version;
+	//   __u8 contents_encryption_mode;
+	//   __u8 filenames_encryption_mode;
+	//   __u8 flags;
+	//   __u8 __reserved[4];
+	//   __u8 master_key_identifier[16];
+	// };
+	policy := make([]byte, 8+fscryptKeyIdentifierSize)
+	policy[0] = fscryptPolicyV2
+	policy[1] = fscryptModeAes256Xts
+	policy[2] = fscryptModeAes256Cts
+	policy[3] = fscryptPolicyFlagPad32
+	copy(policy[8:], identifier[:])
+
+	if err := ioctl(f, fsIocSetEncryptionPolicy, unsafe.Pointer(&policy[0])); err != nil {
+		return fmt.Errorf("FS_IOC_SET_ENCRYPTION_POLICY failed: %w", err)
+	}
+
+	return nil
+}
+
+func ioctl(f *os.File, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// setupEncryption gets (or creates) the per-volume passphrase from the
+// configured KMS and applies it as an fscrypt policy on mountPoint. The
+// caller must not expose the mount to the workload if this returns an
+// error.
+func setupEncryption(ctx context.Context, mountPoint string, volOptions *VolumeOptions) error {
+	if volOptions.Encryption == nil {
+		return nil
+	}
+
+	passphrase, err := volOptions.Encryption.GetCryptoPassphrase(ctx, volOptions.VolID)
+	if err != nil {
+		passphrase, err = volOptions.Encryption.StoreNewCryptoPassphrase(ctx, volOptions.VolID)
+		if err != nil {
+			return fmt.Errorf("failed to get/store encryption passphrase for %q: %w", volOptions.VolID, err)
+		}
+	}
+
+	identifier, err := addEncryptionKey(mountPoint, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to add fscrypt key for %q: %w", volOptions.VolID, err)
+	}
+
+	if err := setEncryptionPolicy(mountPoint, identifier); err != nil {
+		// Policy already set from a previous mount; re-adding the key
+		// above was enough to unlock it.
+		if errors.Is(err, unix.EEXIST) {
+			log.DebugLogMsg("fscrypt policy already set on %s, key unlocked", mountPoint)
+
+			return nil
+		}
+
+		return err
+	}
+
+	log.DebugLogMsg("fscrypt policy applied to %s for volume %s", mountPoint, volOptions.VolID)
+
+	return nil
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2018 The Ceph-CSI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestBuildAddKeyArgLayout golden-byte checks the struct fscrypt_add_key_arg
+// header built for FS_IOC_ADD_ENCRYPTION_KEY against <linux/fscrypt.h>:
+// only FSCRYPT_KEY_SPEC_TYPE_IDENTIFIER (2) is accepted by that ioctl.
+func TestBuildAddKeyArgLayout(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("0123456789abcdef")
+	buf := buildAddKeyArg(key)
+
+	want := make([]byte, fscryptKeySpecSize+4+4+8*4+len(key))
+	want[0] = 2 // FSCRYPT_KEY_SPEC_TYPE_IDENTIFIER
+	want[fscryptKeySpecSize] = byte(len(key))
+
+	rawOff := fscryptKeySpecSize + 4 + 4 + 8*4
+	copy(want[rawOff:], key)
+
+	if !bytes.Equal(buf, want) {
+		t.Fatalf("buildAddKeyArg(%q) = %x, want %x", key, buf, want)
+	}
+
+	if buf[0] != fscryptKeySpecTypeIdentifier {
+		t.Fatalf("key_spec.type = %d, want FSCRYPT_KEY_SPEC_TYPE_IDENTIFIER (%d)",
+			buf[0], fscryptKeySpecTypeIdentifier)
+	}
+}
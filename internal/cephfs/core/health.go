@@ -0,0 +1,178 @@
+/*
+Copyright 2018 The Ceph-CSI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ceph/ceph-csi/internal/util"
+	"github.com/ceph/ceph-csi/internal/util/log"
+)
+
+// healthCheckInterval is how often the supervisor goroutine started from
+// LoadAvailableMounters stats every tracked mountpoint.
+const healthCheckInterval = 30 * time.Second
+
+// trackedMount carries what's needed to remount a stale volume.
+type trackedMount struct {
+	mounter    VolumeMounter
+	cr         *util.Credentials
+	volOptions *VolumeOptions
+}
+
+var (
+	trackedMounts    = make(map[string]*trackedMount)
+	trackedMountsMtx sync.Mutex
+
+	// staleMounts holds mountpoints that failed their last remount attempt.
+	staleMounts    = make(map[string]struct{})
+	staleMountsMtx sync.Mutex
+
+	// mountPointLocks is acquired by VolumeMounter.Mount and UnmountVolume,
+	// so the health checker can never run concurrently with a real mount
+	// or unmount of the same mountpoint, however it's triggered.
+	mountPointLocks = util.NewVolumeLocks()
+)
+
+// MountPointLocks returns the per-mountpoint lock that VolumeMounter.Mount
+// and UnmountVolume already enforce, for callers that need to hold it
+// across more than one call into this package.
+func MountPointLocks() *util.VolumeLocks {
+	return mountPointLocks
+}
+
+// registerMount records the mounter, credentials and options used for
+// mountPoint, for later remount.
+func registerMount(mountPoint string, m VolumeMounter, cr *util.Credentials, volOptions *VolumeOptions) {
+	trackedMountsMtx.Lock()
+	trackedMounts[mountPoint] = &trackedMount{mounter: m, cr: cr, volOptions: volOptions}
+	trackedMountsMtx.Unlock()
+}
+
+// unregisterMount drops the bookkeeping kept for mountPoint.
+func unregisterMount(mountPoint string) {
+	trackedMountsMtx.Lock()
+	delete(trackedMounts, mountPoint)
+	trackedMountsMtx.Unlock()
+
+	staleMountsMtx.Lock()
+	delete(staleMounts, mountPoint)
+	staleMountsMtx.Unlock()
+}
+
+// IsVolumeConditionAbnormal reports whether mountPoint is currently stale.
+func IsVolumeConditionAbnormal(mountPoint string) bool {
+	staleMountsMtx.Lock()
+	defer staleMountsMtx.Unlock()
+	_, ok := staleMounts[mountPoint]
+
+	return ok
+}
+
+// statMountIsStale reports whether mountPoint answers ESTALE or ENOTCONN.
+func statMountIsStale(mountPoint string) bool {
+	var stat syscall.Stat_t
+
+	err := syscall.Stat(mountPoint, &stat)
+
+	return err == syscall.ESTALE || err == syscall.ENOTCONN
+}
+
+// StartHealthChecker launches the supervisor goroutine that periodically
+// checks every tracked mountpoint and remounts it if it has gone stale.
+func StartHealthChecker(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(healthCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				checkTrackedMounts(ctx)
+			}
+		}
+	}()
+}
+
+func checkTrackedMounts(ctx context.Context) {
+	trackedMountsMtx.Lock()
+	mounts := make(map[string]*trackedMount, len(trackedMounts))
+	for mountPoint, tm := range trackedMounts {
+		mounts[mountPoint] = tm
+	}
+	trackedMountsMtx.Unlock()
+
+	for mountPoint, tm := range mounts {
+		if !tm.mounter.IsStale(ctx, mountPoint) {
+			continue
+		}
+
+		if !mountPointLocks.TryAcquire(mountPoint) {
+			log.DebugLogMsg("mount %s is busy with a CSI RPC, deferring remount to the next check", mountPoint)
+
+			continue
+		}
+
+		log.WarningLog(ctx, "mount %s is stale, attempting remount with %s", mountPoint, tm.mounter.Name())
+
+		err := remount(ctx, mountPoint, tm)
+		mountPointLocks.Release(mountPoint)
+
+		if err != nil {
+			log.ErrorLogMsg("failed to remount %s: %v", mountPoint, err)
+			staleMountsMtx.Lock()
+			staleMounts[mountPoint] = struct{}{}
+			staleMountsMtx.Unlock()
+
+			continue
+		}
+
+		staleMountsMtx.Lock()
+		delete(staleMounts, mountPoint)
+		staleMountsMtx.Unlock()
+	}
+}
+
+// remount assumes the caller already holds mountPointLocks for mountPoint,
+// and therefore calls the *Locked helpers directly instead of
+// UnmountVolume/Mount, which would try to re-acquire it.
+func remount(ctx context.Context, mountPoint string, tm *trackedMount) error {
+	if err := unmountVolumeLocked(ctx, mountPoint); err != nil {
+		log.WarningLog(ctx, "failed to unmount stale mountpoint %s before remount: %v", mountPoint, err)
+	}
+
+	// unmountVolumeLocked() just unregistered mountPoint. Re-register it
+	// before attempting the mount below, so a failed attempt still leaves
+	// the mountpoint tracked for the next tick to retry, instead of
+	// orphaning it from the health checker.
+	registerMount(mountPoint, tm.mounter, tm.cr, tm.volOptions)
+
+	if err := tm.mounter.mountLocked(ctx, mountPoint, tm.cr, tm.volOptions); err != nil {
+		// mountLocked unmounts and unregisters mountPoint itself when
+		// encryption setup fails after the mount; track it again.
+		registerMount(mountPoint, tm.mounter, tm.cr, tm.volOptions)
+
+		return err
+	}
+
+	return nil
+}
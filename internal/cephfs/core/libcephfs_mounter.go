@@ -0,0 +1,152 @@
+/*
+Copyright 2018 The Ceph-CSI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ceph/go-ceph/cephfs"
+
+	"github.com/ceph/ceph-csi/internal/util"
+	"github.com/ceph/ceph-csi/internal/util/log"
+)
+
+const volumeMounterLibCephfs = "libcephfs"
+
+// maps a mountpoint to its go-ceph mount handle.
+var (
+	libCephfsMountMap    = make(map[string]*cephfs.Mount)
+	libCephfsMountMapMtx sync.Mutex
+)
+
+// probeLibCephFS checks whether the go-ceph cephfs bindings can create a
+// mount handle on this system.
+func probeLibCephFS() error {
+	mount, err := cephfs.CreateMount()
+	if err != nil {
+		return fmt.Errorf("failed to create libcephfs mount handle: %w", err)
+	}
+	mount.Release()
+
+	return nil
+}
+
+// LibCephFSMounter mounts CephFS volumes in-process via the go-ceph cephfs
+// bindings.
+type LibCephFSMounter struct{}
+
+func mountLibCephFS(ctx context.Context, mountPoint string, cr *util.Credentials, volOptions *VolumeOptions) error {
+	mount, err := cephfs.CreateMount()
+	if err != nil {
+		return fmt.Errorf("failed to create libcephfs mount handle: %w", err)
+	}
+
+	if err = mount.SetConfigPath(util.CephConfigPath); err != nil {
+		mount.Release()
+
+		return fmt.Errorf("failed to set ceph config path: %w", err)
+	}
+
+	options := map[string]string{
+		"client_mountpoint": volOptions.RootPath,
+		"keyfile":           cr.KeyFile,
+		"name":              cephEntityClientPrefix + cr.ID,
+		"mon_host":          volOptions.Monitors,
+	}
+	if volOptions.FsName != "" {
+		options["client_fs"] = volOptions.FsName
+	}
+
+	for name, value := range options {
+		if err = mount.SetConfigOption(name, value); err != nil {
+			mount.Release()
+
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+
+	if err = mount.Init(); err != nil {
+		mount.Release()
+
+		return fmt.Errorf("failed to init libcephfs mount: %w", err)
+	}
+
+	if err = mount.MountWithRoot(volOptions.RootPath); err != nil {
+		mount.Release()
+
+		return fmt.Errorf("failed to mount %q: %w", volOptions.RootPath, err)
+	}
+
+	log.DebugLogMsg("mounted %q via libcephfs onto %s", volOptions.RootPath, mountPoint)
+
+	libCephfsMountMapMtx.Lock()
+	libCephfsMountMap[mountPoint] = mount
+	libCephfsMountMapMtx.Unlock()
+
+	return nil
+}
+
+func (m *LibCephFSMounter) Mount(
+	ctx context.Context,
+	mountPoint string,
+	cr *util.Credentials,
+	volOptions *VolumeOptions) error {
+	if !mountPointLocks.TryAcquire(mountPoint) {
+		return fmt.Errorf("an operation is already in progress for mount point %s", mountPoint)
+	}
+	defer mountPointLocks.Release(mountPoint)
+
+	return m.mountLocked(ctx, mountPoint, cr, volOptions)
+}
+
+func (m *LibCephFSMounter) mountLocked(
+	ctx context.Context,
+	mountPoint string,
+	cr *util.Credentials,
+	volOptions *VolumeOptions) error {
+	if err := util.CreateMountPoint(mountPoint); err != nil {
+		return err
+	}
+
+	if err := mountLibCephFS(ctx, mountPoint, cr, volOptions); err != nil {
+		return err
+	}
+
+	registerMount(mountPoint, m, cr, volOptions)
+
+	return nil
+}
+
+func (m *LibCephFSMounter) Name() string { return "Ceph libcephfs in-process client" }
+
+// IsStale reports the mount as stale if its tracked mount handle no longer
+// answers a cheap libcephfs call.
+func (m *LibCephFSMounter) IsStale(ctx context.Context, mountPoint string) bool {
+	libCephfsMountMapMtx.Lock()
+	mount, ok := libCephfsMountMap[mountPoint]
+	libCephfsMountMapMtx.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	_, err := mount.CurrentDir()
+
+	return err != nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2018 The Ceph-CSI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MountOptionError is returned by NewMounter when a StorageClass requests a
+// kernel or FUSE mount option that the allow/deny list configured for this
+// driver instance does not permit.
+type MountOptionError struct {
+	Mounter string
+	Option  string
+	Reason  string
+}
+
+func (e *MountOptionError) Error() string {
+	return fmt.Sprintf("mount option %q is not permitted for the %s mounter: %s", e.Option, e.Mounter, e.Reason)
+}
+
+// mountOptionPolicy is an allow/deny list for a single mounter's option
+// namespace. Kernel and FUSE mount options are not the same schema, so each
+// mounter gets its own policy.
+type mountOptionPolicy struct {
+	mounter string
+	allow   map[string]struct{} // empty means "allow anything not denied"
+	deny    map[string]struct{}
+}
+
+func newMountOptionPolicy(mounter string, allow, deny []string) *mountOptionPolicy {
+	p := &mountOptionPolicy{
+		mounter: mounter,
+		allow:   make(map[string]struct{}, len(allow)),
+		deny:    make(map[string]struct{}, len(deny)),
+	}
+
+	for _, opt := range allow {
+		p.allow[opt] = struct{}{}
+	}
+
+	for _, opt := range deny {
+		p.deny[opt] = struct{}{}
+	}
+
+	return p
+}
+
+// defaultDenyOptions blocks mount options that can silently discard writes
+// or otherwise compromise a volume if a tenant is allowed to set them via a
+// StorageClass's mountOptions.
+var defaultDenyOptions = []string{
+	"remount",
+	"dirsync",
+	"recover_session=clean",
+}
+
+var (
+	kernelMountOptionPolicy = newMountOptionPolicy(volumeMounterKernel, nil, defaultDenyOptions)
+	fuseMountOptionPolicy   = newMountOptionPolicy(volumeMounterFuse, nil, defaultDenyOptions)
+)
+
+// SetMountOptionAllowlist reconfigures the allow/deny lists enforced for
+// the kernel and FUSE mounters. Called once at driver start, after parsing
+// the operator-supplied configuration; an empty allow list leaves every
+// option but the denied ones permitted.
+func SetMountOptionAllowlist(kernelAllow, kernelDeny, fuseAllow, fuseDeny []string) {
+	kernelMountOptionPolicy = newMountOptionPolicy(volumeMounterKernel, kernelAllow, append(defaultDenyOptions, kernelDeny...))
+	fuseMountOptionPolicy = newMountOptionPolicy(volumeMounterFuse, fuseAllow, append(defaultDenyOptions, fuseDeny...))
+}
+
+// validate checks every comma-separated option in optionsCSV against the
+// policy, matching both the bare option name (e.g. "dirsync") and the
+// "key=value" form (e.g. "recover_session=clean") against the deny list, so
+// a specific dangerous value can be blocked without banning the option
+// outright.
+func (p *mountOptionPolicy) validate(optionsCSV string) error {
+	for _, opt := range strings.Split(optionsCSV, ",") {
+		opt = strings.TrimSpace(opt)
+		if opt == "" {
+			continue
+		}
+
+		key := opt
+		if idx := strings.Index(opt, "="); idx >= 0 {
+			key = opt[:idx]
+		}
+
+		if _, denied := p.deny[opt]; denied {
+			return &MountOptionError{Mounter: p.mounter, Option: opt, Reason: "option is on the deny list"}
+		}
+		if _, denied := p.deny[key]; denied {
+			return &MountOptionError{Mounter: p.mounter, Option: opt, Reason: "option is on the deny list"}
+		}
+
+		if len(p.allow) == 0 {
+			continue
+		}
+
+		_, allowed := p.allow[opt]
+		_, allowedKey := p.allow[key]
+		if !allowed && !allowedKey {
+			return &MountOptionError{Mounter: p.mounter, Option: opt, Reason: "option is not on the allow list"}
+		}
+	}
+
+	return nil
+}
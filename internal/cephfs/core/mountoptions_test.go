@@ -0,0 +1,102 @@
+/*
+Copyright 2018 The Ceph-CSI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import "testing"
+
+func TestMountOptionPolicyValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		policy  *mountOptionPolicy
+		options string
+		wantErr bool
+	}{
+		{
+			name:    "bare option on the deny list is rejected",
+			policy:  newMountOptionPolicy(volumeMounterKernel, nil, []string{"dirsync"}),
+			options: "noatime,dirsync",
+			wantErr: true,
+		},
+		{
+			name:    "key=value option on the deny list is rejected",
+			policy:  newMountOptionPolicy(volumeMounterKernel, nil, []string{"recover_session=clean"}),
+			options: "recover_session=clean",
+			wantErr: true,
+		},
+		{
+			name:    "same key with a different value is not denied",
+			policy:  newMountOptionPolicy(volumeMounterKernel, nil, []string{"recover_session=clean"}),
+			options: "recover_session=fail",
+			wantErr: false,
+		},
+		{
+			name:    "empty allow list permits anything not denied",
+			policy:  newMountOptionPolicy(volumeMounterFuse, nil, []string{"remount"}),
+			options: "noatime,ro",
+			wantErr: false,
+		},
+		{
+			name:    "non-empty allow list rejects an unlisted option",
+			policy:  newMountOptionPolicy(volumeMounterFuse, []string{"noatime"}, nil),
+			options: "noatime,ro",
+			wantErr: true,
+		},
+		{
+			name:    "non-empty allow list permits a listed option",
+			policy:  newMountOptionPolicy(volumeMounterFuse, []string{"noatime", "ro"}, nil),
+			options: "noatime,ro",
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.policy.validate(tt.options)
+			if tt.wantErr && err == nil {
+				t.Fatalf("validate(%q) = nil, want error", tt.options)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("validate(%q) = %v, want nil", tt.options, err)
+			}
+		})
+	}
+}
+
+func TestSetMountOptionAllowlist(t *testing.T) {
+	origKernel, origFuse := kernelMountOptionPolicy, fuseMountOptionPolicy
+	defer func() { kernelMountOptionPolicy, fuseMountOptionPolicy = origKernel, origFuse }()
+
+	SetMountOptionAllowlist(nil, []string{"ro"}, nil, nil)
+
+	if err := kernelMountOptionPolicy.validate("ro"); err == nil {
+		t.Fatal("expected the widened kernel deny list to reject \"ro\"")
+	}
+
+	// The built-in defaults must still apply on top of the operator's list.
+	if err := kernelMountOptionPolicy.validate("dirsync"); err == nil {
+		t.Fatal("expected the default deny list entry \"dirsync\" to still be rejected")
+	}
+
+	if err := fuseMountOptionPolicy.validate("ro"); err != nil {
+		t.Fatalf("fuse policy should be unaffected by the kernel-only deny list, got %v", err)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 
 	"github.com/ceph/ceph-csi/internal/util"
 	"github.com/ceph/ceph-csi/internal/util/log"
@@ -106,16 +107,34 @@ func LoadAvailableMounters(conf *util.Config) error {
 		availableMounters = append(availableMounters, volumeMounterFuse)
 	}
 
+	if err = probeLibCephFS(); err != nil {
+		log.ErrorLogMsg("failed to load libcephfs mounter: %v", err)
+	} else {
+		log.DefaultLog("loaded mounter: %s", volumeMounterLibCephfs)
+		availableMounters = append(availableMounters, volumeMounterLibCephfs)
+	}
+
 	if len(availableMounters) == 0 {
 		return errors.New("no ceph mounters found on system")
 	}
 
+	StartHealthChecker(context.Background())
+
 	return nil
 }
 
 type VolumeMounter interface {
 	Mount(ctx context.Context, mountPoint string, cr *util.Credentials, volOptions *VolumeOptions) error
 	Name() string
+
+	// IsStale reports whether the mount at mountPoint has stopped serving
+	// IO (e.g. the ceph-fuse daemon died, or the kernel client hit
+	// ESTALE/ENOTCONN after an MDS session was blacklisted).
+	IsStale(ctx context.Context, mountPoint string) bool
+
+	// mountLocked does the real work of Mount. The caller must already
+	// hold mountPointLocks for mountPoint.
+	mountLocked(ctx context.Context, mountPoint string, cr *util.Credentials, volOptions *VolumeOptions) error
 }
 
 func NewMounter(volOptions *VolumeOptions) (VolumeMounter, error) {
@@ -145,9 +164,19 @@ func NewMounter(volOptions *VolumeOptions) (VolumeMounter, error) {
 
 	switch chosenMounter {
 	case volumeMounterFuse:
+		if err := fuseMountOptionPolicy.validate(volOptions.FuseMountOptions); err != nil {
+			return nil, err
+		}
+
 		return &FuseMounter{}, nil
 	case volumeMounterKernel:
+		if err := kernelMountOptionPolicy.validate(volOptions.KernelMountOptions); err != nil {
+			return nil, err
+		}
+
 		return &KernelMounter{}, nil
+	case volumeMounterLibCephfs:
+		return &LibCephFSMounter{}, nil
 	}
 
 	return nil, fmt.Errorf("unknown mounter '%s'", chosenMounter)
@@ -204,6 +233,19 @@ func mountFuse(ctx context.Context, mountPoint string, cr *util.Credentials, vol
 }
 
 func (m *FuseMounter) Mount(
+	ctx context.Context,
+	mountPoint string,
+	cr *util.Credentials,
+	volOptions *VolumeOptions) error {
+	if !mountPointLocks.TryAcquire(mountPoint) {
+		return fmt.Errorf("an operation is already in progress for mount point %s", mountPoint)
+	}
+	defer mountPointLocks.Release(mountPoint)
+
+	return m.mountLocked(ctx, mountPoint, cr, volOptions)
+}
+
+func (m *FuseMounter) mountLocked(
 	ctx context.Context,
 	mountPoint string,
 	cr *util.Credentials,
@@ -212,11 +254,42 @@ func (m *FuseMounter) Mount(
 		return err
 	}
 
-	return mountFuse(ctx, mountPoint, cr, volOptions)
+	if err := mountFuse(ctx, mountPoint, cr, volOptions); err != nil {
+		return err
+	}
+
+	if err := setupEncryption(ctx, mountPoint, volOptions); err != nil {
+		if uErr := unmountVolumeLocked(ctx, mountPoint); uErr != nil {
+			log.WarningLog(ctx, "failed to unmount %s after failed encryption setup: %v", mountPoint, uErr)
+		}
+
+		return err
+	}
+
+	registerMount(mountPoint, m, cr, volOptions)
+
+	return nil
 }
 
 func (m *FuseMounter) Name() string { return "Ceph FUSE driver" }
 
+// IsStale reports the FUSE daemon as stale if its tracked PID is no longer
+// running, or if the mountpoint itself answers with ESTALE/ENOTCONN (the
+// daemon is alive but its MDS session was blacklisted).
+func (m *FuseMounter) IsStale(ctx context.Context, mountPoint string) bool {
+	fusePidMapMtx.Lock()
+	pid, ok := fusePidMap[mountPoint]
+	fusePidMapMtx.Unlock()
+
+	if ok {
+		if err := syscall.Kill(pid, 0); err != nil {
+			return true
+		}
+	}
+
+	return statMountIsStale(mountPoint)
+}
+
 type KernelMounter struct{}
 
 func mountKernel(ctx context.Context, mountPoint string, cr *util.Credentials, volOptions *VolumeOptions) error {
@@ -248,6 +321,19 @@ func mountKernel(ctx context.Context, mountPoint string, cr *util.Credentials, v
 }
 
 func (m *KernelMounter) Mount(
+	ctx context.Context,
+	mountPoint string,
+	cr *util.Credentials,
+	volOptions *VolumeOptions) error {
+	if !mountPointLocks.TryAcquire(mountPoint) {
+		return fmt.Errorf("an operation is already in progress for mount point %s", mountPoint)
+	}
+	defer mountPointLocks.Release(mountPoint)
+
+	return m.mountLocked(ctx, mountPoint, cr, volOptions)
+}
+
+func (m *KernelMounter) mountLocked(
 	ctx context.Context,
 	mountPoint string,
 	cr *util.Credentials,
@@ -256,11 +342,32 @@ func (m *KernelMounter) Mount(
 		return err
 	}
 
-	return mountKernel(ctx, mountPoint, cr, volOptions)
+	if err := mountKernel(ctx, mountPoint, cr, volOptions); err != nil {
+		return err
+	}
+
+	if err := setupEncryption(ctx, mountPoint, volOptions); err != nil {
+		if uErr := unmountVolumeLocked(ctx, mountPoint); uErr != nil {
+			log.WarningLog(ctx, "failed to unmount %s after failed encryption setup: %v", mountPoint, uErr)
+		}
+
+		return err
+	}
+
+	registerMount(mountPoint, m, cr, volOptions)
+
+	return nil
 }
 
 func (m *KernelMounter) Name() string { return "Ceph kernel client" }
 
+// IsStale reports whether the kernel client mount at mountPoint has lost
+// its connection to the MDS (ESTALE after a blacklisted session, ENOTCONN
+// for a dead transport endpoint).
+func (m *KernelMounter) IsStale(ctx context.Context, mountPoint string) bool {
+	return statMountIsStale(mountPoint)
+}
+
 func BindMount(ctx context.Context, from, to string, readOnly bool, mntOptions []string) error {
 	mntOptionSli := strings.Join(mntOptions, ",")
 	if err := execCommandErr(ctx, "mount", "-o", mntOptionSli, from, to); err != nil {
@@ -278,6 +385,35 @@ func BindMount(ctx context.Context, from, to string, readOnly bool, mntOptions [
 }
 
 func UnmountVolume(ctx context.Context, mountPoint string) error {
+	if !mountPointLocks.TryAcquire(mountPoint) {
+		return fmt.Errorf("an operation is already in progress for mount point %s", mountPoint)
+	}
+	defer mountPointLocks.Release(mountPoint)
+
+	return unmountVolumeLocked(ctx, mountPoint)
+}
+
+// unmountVolumeLocked does the real work of UnmountVolume. The caller must
+// already hold mountPointLocks for mountPoint.
+func unmountVolumeLocked(ctx context.Context, mountPoint string) error {
+	defer unregisterMount(mountPoint)
+
+	libCephfsMountMapMtx.Lock()
+	mount, ok := libCephfsMountMap[mountPoint]
+	if ok {
+		delete(libCephfsMountMap, mountPoint)
+	}
+	libCephfsMountMapMtx.Unlock()
+
+	if ok {
+		if err := mount.Unmount(); err != nil {
+			log.WarningLog(ctx, "failed to unmount libcephfs mount %s: %v", mountPoint, err)
+		}
+		mount.Release()
+
+		return nil
+	}
+
 	if _, stderr, err := util.ExecCommand(ctx, "umount", mountPoint); err != nil {
 		err = fmt.Errorf("%w stderr: %s", err, stderr)
 		if strings.Contains(err.Error(), fmt.Sprintf("umount: %s: not mounted", mountPoint)) ||